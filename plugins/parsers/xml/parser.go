@@ -1,8 +1,13 @@
 package xml
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,6 +23,58 @@ var (
 	AttrSelector = regexp.MustCompile(`.*\/@(?P<AttrName>.+)$`)
 )
 
+// FieldConfig describes a single metric field to extract via XPath, with an
+// explicit type so the value isn't guessed by convertField.
+type FieldConfig struct {
+	Name   string
+	Query  string
+	Type   string
+	Format string // layout used when Type is "time"
+}
+
+// TagConfig describes a single metric tag to extract via XPath.
+type TagConfig struct {
+	Name  string
+	Query string
+}
+
+// XMLMetricConfig describes one measurement to extract from a document,
+// independently of any other entries in XMLParser.Configs. It mirrors the
+// single-config fields of XMLParser itself so the same document can yield
+// several distinct measurements in one Parse call.
+type XMLMetricConfig struct {
+	MetricName      string
+	Query           string
+	Measurement     string
+	TagKeys         []string
+	Fields          []FieldConfig
+	Tags            []TagConfig
+	Timestamp       string
+	TimestampFormat string
+}
+
+// toParser builds the *XMLParser used to evaluate this config, inheriting
+// cross-cutting settings (Namespaces, DefaultTags, MergeNodes, ...) from
+// base while overriding everything this config specifies itself.
+func (c XMLMetricConfig) toParser(base *XMLParser) *XMLParser {
+	cp := *base
+	cp.Configs = nil
+	cp.MetricName = c.MetricName
+	cp.Query = c.Query
+	if cp.Query == "" {
+		cp.Query = "//"
+	}
+	cp.Measurement = c.Measurement
+	cp.TagKeys = c.TagKeys
+	cp.Fields = c.Fields
+	cp.Tags = c.Tags
+	cp.TimestampQuery = c.Timestamp
+	if c.TimestampFormat != "" {
+		cp.TimestampFormat = c.TimestampFormat
+	}
+	return &cp
+}
+
 type XMLParser struct {
 	MetricName  string
 	TagKeys     []string
@@ -27,6 +84,102 @@ type XMLParser struct {
 	Query       string
 	Measurement string
 	DefaultTags map[string]string
+
+	// Fields and Tags select specific values per root match instead of
+	// walking every descendant node. When either is non-empty they take
+	// over from the legacy walk-everything behavior.
+	Fields []FieldConfig
+	Tags   []TagConfig
+
+	// TimestampQuery, when set, selects the metric timestamp from each
+	// root match; TimestampFormat is the layout used to parse it and
+	// defaults to time.RFC3339.
+	TimestampQuery  string
+	TimestampFormat string
+
+	// Namespaces maps a symbolic prefix used in Query, Measurement and any
+	// per-field/tag XPath to its namespace URI. Every query is rewritten
+	// against the actual prefix the document declares for that URI before
+	// it is compiled, so the same Namespaces config works regardless of
+	// which literal prefix a given document happens to use.
+	Namespaces map[string]string
+
+	// KeepNamespaceInName keeps the "prefix:local" form for tag/field keys
+	// derived from the legacy walk-everything mode. By default the prefix
+	// is stripped so the metric schema stays stable across documents that
+	// declare the same namespace under different prefixes.
+	KeepNamespaceInName bool
+
+	// StreamRootElement is the local name of the element ParseStream
+	// buffers into memory one match at a time, instead of loading the
+	// whole document as Parse does.
+	StreamRootElement string
+
+	// TypeHints maps a tag or attribute name to an explicit type, consulted
+	// by the legacy walk-everything mode before it falls back to the
+	// numeric/bool guessing in convertField. Supported values are "string",
+	// "int", "uint", "float", "bool", "base64", "hex", "duration" and
+	// "timestamp:<layout>" (layout defaults to time.RFC3339).
+	TypeHints map[string]string
+
+	// TimestampKey, when it matches a tag/attribute name with a "timestamp"
+	// hint, overrides the metric's timestamp with the parsed value.
+	TimestampKey string
+
+	// StrictTypes turns a TypeHints mismatch into an error instead of
+	// silently falling back to the string value.
+	StrictTypes bool
+
+	// Configs, when non-empty, makes Parse emit one set of metrics per
+	// entry against the same parsed document, instead of using this
+	// XMLParser's own Query/Measurement/Fields/Tags.
+	Configs []XMLMetricConfig
+
+	// Options controls the XXE/billion-laughs safeguards applied before a
+	// document reaches etree. A nil Options is equivalent to
+	// XMLParserOptions{DisallowDOCTYPE: true}.
+	Options *XMLParserOptions
+}
+
+// XMLParserOptions bounds how much trust Parse/ParseStream place in the raw
+// document before handing it to etree.
+type XMLParserOptions struct {
+	// DisallowDOCTYPE rejects any document containing a DOCTYPE
+	// declaration, which is where XXE and billion-laughs entity bombs are
+	// defined. Defaults to true when Options is nil.
+	DisallowDOCTYPE bool
+
+	// MaxDepth, MaxElementCount and MaxTextLength bound element nesting,
+	// total element count and the length of any single text node; zero
+	// means unbounded. ParseStream applies them to each buffered
+	// StreamRootElement subtree on its own: depth is counted from that
+	// element, the count restarts for every subtree, and the text length is
+	// all character data directly inside one element taken together.
+	MaxDepth        int
+	MaxElementCount int
+	MaxTextLength   int
+
+	// ResolveXInclude performs one pass of xi:include href="..." resolution
+	// against Resolver after the document is parsed, letting callers
+	// compose multi-file payloads without enabling unsafe entity
+	// resolution to do it.
+	ResolveXInclude bool
+	Resolver        func(href string) ([]byte, error)
+}
+
+// options returns the effective options, applying the safe defaults when
+// p.Options hasn't been set.
+func (p *XMLParser) options() XMLParserOptions {
+	if p.Options == nil {
+		return XMLParserOptions{DisallowDOCTYPE: true}
+	}
+	return *p.Options
+}
+
+// SetNamespaces configures the prefix-to-URI map used to resolve
+// namespace-qualified XPath queries.
+func (p *XMLParser) SetNamespaces(namespaces map[string]string) {
+	p.Namespaces = namespaces
 }
 
 func NewXMLParser(
@@ -57,6 +210,12 @@ func NewXMLParser(
 
 func (p *XMLParser) Parse(b []byte) ([]telegraf.Metric, error) {
 	timestamp := time.Now().UTC()
+	opts := p.options()
+
+	if err := checkXMLSafety(b, opts); err != nil {
+		return nil, err
+	}
+
 	xmlDocument := etree.NewDocument()
 
 	err := xmlDocument.ReadFromBytes(b)
@@ -64,7 +223,35 @@ func (p *XMLParser) Parse(b []byte) ([]telegraf.Metric, error) {
 		return nil, err
 	}
 
-	path, err := etree.CompilePath(p.Query)
+	if opts.ResolveXInclude {
+		xiPrefix := "xi"
+		if bound, ok := documentNamespacePrefixes(xmlDocument)[xincludeNamespaceURI]; ok {
+			xiPrefix = bound
+		}
+		if err := resolveXIncludes(&xmlDocument.Element, xiPrefix, opts.Resolver); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(p.Configs) > 0 {
+		results := make([]telegraf.Metric, 0)
+		for _, cfg := range p.Configs {
+			metrics, err := cfg.toParser(p).parseDocument(xmlDocument, timestamp)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, metrics...)
+		}
+		return results, nil
+	}
+
+	return p.parseDocument(xmlDocument, timestamp)
+}
+
+// parseDocument runs this parser's single Query/Measurement/Fields/Tags
+// configuration against an already-parsed document.
+func (p *XMLParser) parseDocument(xmlDocument *etree.Document, timestamp time.Time) ([]telegraf.Metric, error) {
+	path, err := etree.CompilePath(p.resolveNamespaces(xmlDocument, p.Query))
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +259,7 @@ func (p *XMLParser) Parse(b []byte) ([]telegraf.Metric, error) {
 	root := xmlDocument.FindElementsPath(path)
 
 	if len(p.Measurement) > 0 {
-		name, err := selectSingleValue(&xmlDocument.Element, p.Measurement)
+		name, err := selectSingleValue(&xmlDocument.Element, p.resolveNamespaces(xmlDocument, p.Measurement))
 		if err != nil {
 			return nil, err
 		}
@@ -80,16 +267,210 @@ func (p *XMLParser) Parse(b []byte) ([]telegraf.Metric, error) {
 	}
 
 	if len := len(root); len > 0 {
-		if p.ParseArray == true {
-			return p.ParseAsArray(root, timestamp)
-		} else {
-			return p.ParseAsObject(root, timestamp)
-		}
+		return p.parseNodes(xmlDocument, root, timestamp)
 	}
 
 	return make([]telegraf.Metric, 0), nil
 }
 
+// parseNodes dispatches a set of already-located root elements to the
+// configured-fields, array, or object extraction mode, whichever applies.
+func (p *XMLParser) parseNodes(doc *etree.Document, nodes []*etree.Element, timestamp time.Time) ([]telegraf.Metric, error) {
+	if len(p.Fields) > 0 || len(p.Tags) > 0 {
+		return p.ParseConfiguredFields(doc, nodes, timestamp)
+	}
+
+	if p.ParseArray == true {
+		return p.ParseAsArray(nodes, timestamp)
+	}
+	return p.ParseAsObject(nodes, timestamp)
+}
+
+// ParseStream reads XML from r one token at a time and, each time an
+// element named StreamRootElement is encountered, buffers only that
+// subtree into memory before handing it to the same extraction logic as
+// Parse. This keeps memory bounded by the size of a single record rather
+// than the whole document, unlike Parse which loads the full DOM upfront.
+// Namespaces are resolved only against declarations inside the buffered
+// subtree; declarations on its ancestors are not seen, so a prefixed query
+// step then matches only the same literal prefix.
+func (p *XMLParser) ParseStream(r io.Reader) ([]telegraf.Metric, error) {
+	if p.StreamRootElement == "" {
+		return nil, errors.New("xml: ParseStream requires StreamRootElement to be set")
+	}
+
+	opts := p.options()
+	decoder := xml.NewDecoder(r)
+	timestamp := time.Now().UTC()
+	results := make([]telegraf.Metric, 0)
+
+	for {
+		tok, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if d, ok := tok.(xml.Directive); ok && opts.DisallowDOCTYPE && isDoctypeDirective(d) {
+			return nil, errDOCTYPENotAllowed
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != p.StreamRootElement {
+			continue
+		}
+
+		element, err := bufferElement(decoder, start, opts, 1, &elementBudget{})
+		if err != nil {
+			return nil, err
+		}
+
+		doc := etree.NewDocument()
+		doc.SetRoot(element)
+
+		metrics, err := p.parseNodes(doc, []*etree.Element{element}, timestamp)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, metrics...)
+	}
+
+	return results, nil
+}
+
+// elementBudget tracks the running element count across an entire buffered
+// subtree, shared by every recursive bufferElement call for that subtree.
+type elementBudget struct {
+	count int
+}
+
+// bufferElement consumes tokens from decoder until the matching end of
+// start, building an in-memory etree.Element for just that subtree, while
+// enforcing opts' depth/count/text-length limits.
+func bufferElement(decoder *xml.Decoder, start xml.StartElement, opts XMLParserOptions, depth int, budget *elementBudget) (*etree.Element, error) {
+	if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+		return nil, fmt.Errorf("xml: exceeds max depth %d", opts.MaxDepth)
+	}
+
+	budget.count++
+	if opts.MaxElementCount > 0 && budget.count > opts.MaxElementCount {
+		return nil, fmt.Errorf("xml: exceeds max element count %d", opts.MaxElementCount)
+	}
+
+	element := etree.NewElement(qualifiedName(start.Name.Space, start.Name.Local))
+
+	for _, attr := range start.Attr {
+		element.CreateAttr(qualifiedName(attr.Name.Space, attr.Name.Local), attr.Value)
+	}
+
+	var text strings.Builder
+	for {
+		tok, err := decoder.RawToken()
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			child, err := bufferElement(decoder, t, opts, depth+1, budget)
+			if err != nil {
+				return nil, err
+			}
+			element.AddChild(child)
+		case xml.CharData:
+			text.Write(t)
+			if opts.MaxTextLength > 0 && text.Len() > opts.MaxTextLength {
+				return nil, fmt.Errorf("xml: text node exceeds max length %d", opts.MaxTextLength)
+			}
+		case xml.EndElement:
+			if s := trimEmptyChars(text.String()); s != "" {
+				element.SetText(s)
+			}
+			return element, nil
+		}
+	}
+}
+
+// qualifiedName rebuilds a "prefix:local" tag/attribute name from a
+// decoder.RawToken's unresolved Name, so etree.NewElement/CreateAttr split
+// it into Space/Tag themselves exactly as they would from raw document
+// bytes, rather than trusting encoding/xml's own namespace resolution.
+func qualifiedName(space, local string) string {
+	if space == "" {
+		return local
+	}
+	return space + ":" + local
+}
+
+// ParseConfiguredFields emits one metric per root match, with tags and
+// fields taken from the configured Tags/Fields XPath queries rather than
+// walking every descendant node.
+func (p *XMLParser) ParseConfiguredFields(doc *etree.Document, nodes []*etree.Element, timestamp time.Time) ([]telegraf.Metric, error) {
+	results := make([]telegraf.Metric, 0, len(nodes))
+
+	for _, e := range nodes {
+		tags := make(map[string]string)
+		fields := make(map[string]interface{})
+
+		for _, tc := range p.Tags {
+			value, err := selectSingleValue(e, p.resolveNamespaces(doc, tc.Query))
+			if err != nil {
+				return nil, fmt.Errorf("tag %q: %w", tc.Name, err)
+			}
+			tags[tc.Name] = value
+		}
+
+		for _, fc := range p.Fields {
+			value, err := selectSingleValue(e, p.resolveNamespaces(doc, fc.Query))
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", fc.Name, err)
+			}
+
+			converted, err := convertTypedField(value, fc.Type, fc.Format)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", fc.Name, err)
+			}
+			fields[fc.Name] = converted
+		}
+
+		metricTime := timestamp
+		if p.TimestampQuery != "" {
+			value, err := selectSingleValue(e, p.resolveNamespaces(doc, p.TimestampQuery))
+			if err != nil {
+				return nil, fmt.Errorf("timestamp: %w", err)
+			}
+
+			parsed, err := time.Parse(p.timestampFormat(), value)
+			if err != nil {
+				return nil, fmt.Errorf("timestamp: %w", err)
+			}
+			metricTime = parsed
+		}
+
+		if p.TagNode == true {
+			tags["xml_node_name"] = e.Tag
+		}
+
+		tags = mergeTwoTagMaps(tags, p.DefaultTags)
+		m, err := metric.New(p.MetricName, tags, fields, metricTime)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, m)
+	}
+
+	return results, nil
+}
+
+func (p *XMLParser) timestampFormat() string {
+	if p.TimestampFormat == "" {
+		return time.RFC3339
+	}
+	return p.TimestampFormat
+}
+
 func (p *XMLParser) ParseLine(line string) (telegraf.Metric, error) {
 	metrics, err := p.Parse([]byte(line))
 	if err != nil {
@@ -108,15 +489,29 @@ func (p *XMLParser) ParseAsArray(nodes []*etree.Element, timestamp time.Time) ([
 	xmlFields := make(map[string]interface{})
 
 	for _, e := range nodes {
+		nodeTimestamp := timestamp
+
 		for _, t := range e.FindElements(".//") {
-			tags, fields := p.ParseXmlNode(t)
+			tags, fields, ts, err := p.ParseXmlNode(t)
+			if err != nil {
+				return nil, err
+			}
 			xmlTags = mergeTwoTagMaps(xmlTags, tags)
 			xmlFields = mergeTwoFieldMaps(xmlFields, fields)
+			if ts != nil {
+				nodeTimestamp = *ts
+			}
 		}
 
-		tags, fields := p.ParseXmlNode(e)
+		tags, fields, ts, err := p.ParseXmlNode(e)
+		if err != nil {
+			return nil, err
+		}
 		xmlTags = mergeTwoTagMaps(xmlTags, tags)
 		xmlFields = mergeTwoFieldMaps(xmlFields, fields)
+		if ts != nil {
+			nodeTimestamp = *ts
+		}
 
 		if p.TagNode == true {
 			xmlTags["xml_node_name"] = e.Tag
@@ -124,7 +519,7 @@ func (p *XMLParser) ParseAsArray(nodes []*etree.Element, timestamp time.Time) ([
 
 		// add default tags
 		xmlTags = mergeTwoTagMaps(xmlTags, p.DefaultTags)
-		metric, err := metric.New(p.MetricName, xmlTags, xmlFields, timestamp)
+		metric, err := metric.New(p.MetricName, xmlTags, xmlFields, nodeTimestamp)
 		if err != nil {
 			return nil, err
 		}
@@ -141,21 +536,31 @@ func (p *XMLParser) ParseAsObject(nodes []*etree.Element, timestamp time.Time) (
 	results := make([]telegraf.Metric, 0)
 	xmlTags := make(map[string]string)
 	xmlFields := make(map[string]interface{})
+	mergedTimestamp := timestamp
 
 	for _, e := range nodes {
-		tags, fields := p.ParseXmlNode(e)
+		tags, fields, ts, err := p.ParseXmlNode(e)
+		if err != nil {
+			return nil, err
+		}
 
 		if p.TagNode == true {
 			tags["xml_node_name"] = e.Tag
 		}
 
+		nodeTimestamp := timestamp
+		if ts != nil {
+			nodeTimestamp = *ts
+			mergedTimestamp = *ts
+		}
+
 		if p.MergeNodes == true {
 			xmlTags = mergeTwoTagMaps(xmlTags, tags)
 			xmlFields = mergeTwoFieldMaps(xmlFields, fields)
 		} else {
 			// add default tags
 			tags = mergeTwoTagMaps(tags, p.DefaultTags)
-			metric, err := metric.New(p.MetricName, tags, fields, timestamp)
+			metric, err := metric.New(p.MetricName, tags, fields, nodeTimestamp)
 			if err != nil {
 				return nil, err
 			}
@@ -166,7 +571,7 @@ func (p *XMLParser) ParseAsObject(nodes []*etree.Element, timestamp time.Time) (
 	if p.MergeNodes == true {
 		// add default tags
 		xmlTags = mergeTwoTagMaps(xmlTags, p.DefaultTags)
-		metric, err := metric.New(p.MetricName, xmlTags, xmlFields, timestamp)
+		metric, err := metric.New(p.MetricName, xmlTags, xmlFields, mergedTimestamp)
 		if err != nil {
 			return nil, err
 		}
@@ -176,33 +581,128 @@ func (p *XMLParser) ParseAsObject(nodes []*etree.Element, timestamp time.Time) (
 	return results, nil
 }
 
-func (p *XMLParser) ParseXmlNode(node *etree.Element) (tags map[string]string, fields map[string]interface{}) {
+func (p *XMLParser) ParseXmlNode(node *etree.Element) (tags map[string]string, fields map[string]interface{}, tsOverride *time.Time, err error) {
 	tags = make(map[string]string)
 	fields = make(map[string]interface{})
 
+	nodeName := p.nodeFieldName(node.Space, node.Tag)
 	nodeText := trimEmptyChars(node.Text())
 	if nodeText != "" {
 		if p.isTag(node.Tag) {
-			tags[node.Tag] = node.Text()
+			tags[nodeName] = node.Text()
 		} else {
-			fields[node.Tag] = convertField(node.Text())
+			value, ts, err := p.convertWithHint(nodeName, node.Text())
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			fields[nodeName] = value
+			if ts != nil {
+				tsOverride = ts
+			}
 		}
 	}
 
 	attrs := node.Attr
 	if len := len(attrs); len > 0 {
 		for _, e := range attrs {
+			attrName := p.nodeFieldName(e.Space, e.Key)
 			attrText := trimEmptyChars(e.Value)
 			if attrText != "" {
 				if p.isTag(e.Key) {
-					tags[e.Key] = e.Value
+					tags[attrName] = e.Value
 				} else {
-					fields[e.Key] = convertField(e.Value)
+					value, ts, err := p.convertWithHint(attrName, e.Value)
+					if err != nil {
+						return nil, nil, nil, err
+					}
+					fields[attrName] = value
+					if ts != nil {
+						tsOverride = ts
+					}
 				}
 			}
 		}
 	}
-	return tags, fields
+	return tags, fields, tsOverride, nil
+}
+
+// nodeFieldName builds the key used for a tag/field derived from a walked
+// node, stripping the namespace prefix unless KeepNamespaceInName is set.
+func (p *XMLParser) nodeFieldName(space, local string) string {
+	if p.KeepNamespaceInName && space != "" {
+		return space + ":" + local
+	}
+	return local
+}
+
+// namespaceStepPattern matches a "prefix:local" XPath step, whether it
+// appears as an element step ("/prefix:local") or an attribute step
+// ("@prefix:local").
+var namespaceStepPattern = regexp.MustCompile(`(^|/|@)([A-Za-z_][\w.-]*):([A-Za-z_][\w.-]*)`)
+
+// resolveNamespaces rewrites every "prefix:local" step in query whose prefix
+// is one of p.Namespaces' keys into whatever prefix doc itself declares for
+// that namespace URI (or no prefix at all, if it is the default namespace).
+// Queries are left untouched when no Namespaces are configured, or when a
+// step's prefix or URI isn't declared by doc.
+func (p *XMLParser) resolveNamespaces(doc *etree.Document, query string) string {
+	if len(p.Namespaces) == 0 {
+		return query
+	}
+
+	docPrefixes := documentNamespacePrefixes(doc)
+
+	return namespaceStepPattern.ReplaceAllStringFunc(query, func(step string) string {
+		m := namespaceStepPattern.FindStringSubmatch(step)
+		lead, prefix, local := m[1], m[2], m[3]
+
+		uri, ok := p.Namespaces[prefix]
+		if !ok {
+			return step
+		}
+
+		actual, ok := docPrefixes[uri]
+		if !ok {
+			return step
+		}
+
+		if actual == "" {
+			return lead + local
+		}
+		return lead + actual + ":" + local
+	})
+}
+
+// documentNamespacePrefixes returns the namespace URI to literal-prefix
+// mapping declared anywhere in doc (the default namespace, if any, maps to
+// the empty prefix). Real-world documents such as SOAP responses routinely
+// declare an operation's namespace on a nested element rather than the
+// root, so every element in the document is scanned, not just the root.
+func documentNamespacePrefixes(doc *etree.Document) map[string]string {
+	prefixes := make(map[string]string)
+
+	root := doc.Root()
+	if root == nil {
+		return prefixes
+	}
+
+	collectNamespacePrefixes(root, prefixes)
+	return prefixes
+}
+
+func collectNamespacePrefixes(el *etree.Element, prefixes map[string]string) {
+	for _, attr := range el.Attr {
+		switch {
+		case attr.Space == "xmlns":
+			prefixes[attr.Value] = attr.Key
+		case attr.Key == "xmlns":
+			prefixes[attr.Value] = ""
+		}
+	}
+
+	for _, child := range el.ChildElements() {
+		collectNamespacePrefixes(child, prefixes)
+	}
 }
 
 func selectSingleValue(doc *etree.Element, query string) (string, error) {
@@ -249,6 +749,109 @@ func selectSingleNode(doc *etree.Element, query string) (*etree.Element, error)
 	return node, nil
 }
 
+var errDOCTYPENotAllowed = errors.New("xml: DOCTYPE declarations are not allowed")
+
+// isDoctypeDirective reports whether an xml.Directive token is a DOCTYPE
+// declaration, the only place external entities and billion-laughs style
+// entity expansions can be defined.
+func isDoctypeDirective(d xml.Directive) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(d), []byte("DOCTYPE"))
+}
+
+// checkXMLSafety performs a single read-only pass over b with encoding/xml,
+// rejecting DOCTYPE declarations and any depth/element-count/text-length
+// limit violations before the document is handed to etree.
+func checkXMLSafety(b []byte, opts XMLParserOptions) error {
+	if !opts.DisallowDOCTYPE && opts.MaxDepth <= 0 && opts.MaxElementCount <= 0 && opts.MaxTextLength <= 0 {
+		return nil
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+	depth := 0
+	elementCount := 0
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.Directive:
+			if opts.DisallowDOCTYPE && isDoctypeDirective(t) {
+				return errDOCTYPENotAllowed
+			}
+		case xml.StartElement:
+			depth++
+			elementCount++
+			if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+				return fmt.Errorf("xml: exceeds max depth %d", opts.MaxDepth)
+			}
+			if opts.MaxElementCount > 0 && elementCount > opts.MaxElementCount {
+				return fmt.Errorf("xml: exceeds max element count %d", opts.MaxElementCount)
+			}
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if opts.MaxTextLength > 0 && len(t) > opts.MaxTextLength {
+				return fmt.Errorf("xml: text node exceeds max length %d", opts.MaxTextLength)
+			}
+		}
+	}
+}
+
+// xincludeNamespaceURI is the namespace XInclude's "include" element is
+// defined in. The XInclude spec permits binding it to any literal prefix,
+// so resolveXIncludes is always called with the prefix actually bound to
+// this URI in the document being processed (falling back to the "xi"
+// convention when the document never binds it at all).
+const xincludeNamespaceURI = "http://www.w3.org/2001/XInclude"
+
+// resolveXIncludes walks parent's descendants depth-first, replacing every
+// <xiPrefix:include href="..."> element with the root element of the
+// document its href resolves to via resolve.
+func resolveXIncludes(parent *etree.Element, xiPrefix string, resolve func(href string) ([]byte, error)) error {
+	if resolve == nil {
+		return errors.New("xml: ResolveXInclude requires a Resolver")
+	}
+
+	for _, child := range parent.ChildElements() {
+		if child.Space == xiPrefix && child.Tag == "include" {
+			href := child.SelectAttrValue("href", "")
+			if href == "" {
+				return errors.New("xml: xi:include is missing an href attribute")
+			}
+
+			data, err := resolve(href)
+			if err != nil {
+				return fmt.Errorf("xml: resolving xi:include href %q: %w", href, err)
+			}
+
+			included := etree.NewDocument()
+			if err := included.ReadFromBytes(data); err != nil {
+				return fmt.Errorf("xml: parsing xi:include href %q: %w", href, err)
+			}
+
+			includedRoot := included.Root()
+			if includedRoot == nil {
+				return fmt.Errorf("xml: xi:include href %q produced no content", href)
+			}
+
+			parent.InsertChild(child, includedRoot)
+			parent.RemoveChild(child)
+			continue
+		}
+
+		if err := resolveXIncludes(child, xiPrefix, resolve); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *XMLParser) isTag(str string) bool {
 	for _, a := range p.TagKeys {
 		if a == str {
@@ -284,6 +887,79 @@ func convertField(value string) interface{} {
 	}
 }
 
+func convertTypedField(value, typ, format string) (interface{}, error) {
+	switch typ {
+	case "", "string":
+		return value, nil
+	case "int":
+		return strconv.ParseInt(value, 10, 64)
+	case "uint":
+		return strconv.ParseUint(value, 10, 64)
+	case "float":
+		return strconv.ParseFloat(value, 64)
+	case "bool":
+		return strconv.ParseBool(value)
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(decoded), nil
+	case "hex":
+		decoded, err := hex.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		return string(decoded), nil
+	case "duration":
+		return time.ParseDuration(value)
+	case "time", "timestamp":
+		layout := format
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return time.Parse(layout, value)
+	default:
+		return nil, fmt.Errorf("unknown field type %q", typ)
+	}
+}
+
+// parseTypeHint splits a TypeHints value such as "timestamp:2006-01-02" into
+// its type and an optional format/layout.
+func parseTypeHint(hint string) (typ, format string) {
+	if idx := strings.IndexByte(hint, ':'); idx >= 0 {
+		return hint[:idx], hint[idx+1:]
+	}
+	return hint, ""
+}
+
+// convertWithHint coerces value using the TypeHints entry for name, if any,
+// falling back to the untyped convertField heuristic otherwise. It also
+// returns a non-nil timestamp when name matches TimestampKey and the hinted
+// value parsed as a timestamp.
+func (p *XMLParser) convertWithHint(name, value string) (interface{}, *time.Time, error) {
+	hint, ok := p.TypeHints[name]
+	if !ok {
+		return convertField(value), nil, nil
+	}
+
+	typ, format := parseTypeHint(hint)
+
+	converted, err := convertTypedField(value, typ, format)
+	if err != nil {
+		if p.StrictTypes {
+			return nil, nil, fmt.Errorf("type hint %q for %q: %w", hint, name, err)
+		}
+		return value, nil, nil
+	}
+
+	var override *time.Time
+	if t, ok := converted.(time.Time); ok && p.TimestampKey != "" && name == p.TimestampKey {
+		override = &t
+	}
+	return converted, override, nil
+}
+
 func trimEmptyChars(s string) string {
 	text := strings.Trim(s, "\n\r\t ")
 	return text
@@ -0,0 +1,280 @@
+package xml
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceAwareQuery_SOAPNestedDefaultNamespace(t *testing.T) {
+	// The namespace that matters here is declared on the nested response
+	// element, as real SOAP responses typically do, not on the envelope.
+	soap := []byte(`<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <GetStockPriceResponse xmlns="http://example.com/stockquote">
+      <Price>34.5</Price>
+    </GetStockPriceResponse>
+  </soap:Body>
+</soap:Envelope>`)
+
+	parser := &XMLParser{
+		MetricName: "stock",
+		Query:      "//stock:GetStockPriceResponse",
+		Namespaces: map[string]string{"stock": "http://example.com/stockquote"},
+		Fields: []FieldConfig{
+			{Name: "price", Query: "stock:Price", Type: "float"},
+		},
+	}
+
+	metrics, err := parser.Parse(soap)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "stock", metrics[0].Name())
+	assert.Equal(t, 34.5, metrics[0].Fields()["price"])
+}
+
+func TestNamespaceAwareQuery_SitemapDifferentLiteralPrefix(t *testing.T) {
+	// The document binds the image namespace to "myimg", but the parser is
+	// configured with the symbolic prefix "img" -- resolution must go via
+	// the namespace URI, not the literal text.
+	sitemap := []byte(`<?xml version="1.0"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+        xmlns:myimg="http://www.google.com/schemas/sitemap-image/1.1">
+  <url>
+    <loc>http://example.com/1</loc>
+    <myimg:image>
+      <myimg:loc>http://example.com/1.jpg</myimg:loc>
+    </myimg:image>
+  </url>
+</urlset>`)
+
+	parser := &XMLParser{
+		MetricName: "sitemap_image",
+		Query:      "//img:image",
+		Namespaces: map[string]string{"img": "http://www.google.com/schemas/sitemap-image/1.1"},
+		Fields: []FieldConfig{
+			{Name: "loc", Query: "img:loc"},
+		},
+	}
+
+	metrics, err := parser.Parse(sitemap)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "http://example.com/1.jpg", metrics[0].Fields()["loc"])
+}
+
+func TestNamespaceAwareQuery_AtomFeed(t *testing.T) {
+	atom := []byte(`<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <entry>
+    <title>First post</title>
+  </entry>
+</feed>`)
+
+	parser := &XMLParser{
+		MetricName: "atom_entry",
+		Query:      "//atom:entry",
+		Namespaces: map[string]string{"atom": "http://www.w3.org/2005/Atom"},
+		Fields: []FieldConfig{
+			{Name: "title", Query: "atom:title"},
+		},
+	}
+
+	metrics, err := parser.Parse(atom)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "First post", metrics[0].Fields()["title"])
+}
+
+func TestParseStream_NamespacedElements(t *testing.T) {
+	doc := `<records xmlns:m="http://example.com/metrics">
+  <m:record><m:value>1</m:value></m:record>
+  <m:record><m:value>2</m:value></m:record>
+</records>`
+
+	parser := &XMLParser{
+		MetricName:        "record",
+		StreamRootElement: "record",
+		Namespaces:        map[string]string{"m": "http://example.com/metrics"},
+		Fields: []FieldConfig{
+			{Name: "value", Query: "m:value", Type: "int"},
+		},
+	}
+
+	metrics, err := parser.ParseStream(strings.NewReader(doc))
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	assert.EqualValues(t, 1, metrics[0].Fields()["value"])
+	assert.EqualValues(t, 2, metrics[1].Fields()["value"])
+}
+
+func TestTypeHints_StrictMismatchErrors(t *testing.T) {
+	// The default Query ("//") matches every element in the document, so
+	// MergeNodes must be set to fold the "reading" and "zip" matches back
+	// into a single metric instead of emitting one per matched node.
+	doc := []byte(`<reading><zip>02139-1234</zip></reading>`)
+
+	parser := NewXMLParser("reading", true, false, false, "", "", nil, nil)
+	parser.TypeHints = map[string]string{"zip": "string"}
+
+	metrics, err := parser.Parse(doc)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "02139-1234", metrics[0].Fields()["zip"])
+
+	parser.TypeHints = map[string]string{"zip": "int"}
+	parser.StrictTypes = true
+	_, err = parser.Parse(doc)
+	assert.Error(t, err)
+}
+
+func TestMultiConfig_EmitsOneMetricPerConfig(t *testing.T) {
+	doc := []byte(`<status>
+  <cpu><usage>42</usage></cpu>
+  <iface><rx>1000</rx></iface>
+</status>`)
+
+	parser := &XMLParser{
+		Configs: []XMLMetricConfig{
+			{
+				MetricName: "cpu",
+				Query:      "//cpu",
+				Fields:     []FieldConfig{{Name: "usage", Query: "usage", Type: "int"}},
+			},
+			{
+				MetricName: "iface",
+				Query:      "//iface",
+				Fields:     []FieldConfig{{Name: "rx", Query: "rx", Type: "int"}},
+			},
+		},
+	}
+
+	metrics, err := parser.Parse(doc)
+	require.NoError(t, err)
+	require.Len(t, metrics, 2)
+	assert.Equal(t, "cpu", metrics[0].Name())
+	assert.Equal(t, "iface", metrics[1].Name())
+}
+
+func TestConfiguredFields_TagViaXPathAndTimestampQuery(t *testing.T) {
+	doc := []byte(`<reading>
+  <sensor id="a1">
+    <location>room1</location>
+    <value>21.5</value>
+    <recordedAt>2024-01-02T15:04:05Z</recordedAt>
+  </sensor>
+</reading>`)
+
+	parser := &XMLParser{
+		MetricName: "reading",
+		Query:      "//sensor",
+		Tags: []TagConfig{
+			{Name: "location", Query: "location"},
+		},
+		Fields: []FieldConfig{
+			{Name: "value", Query: "value", Type: "float"},
+		},
+		TimestampQuery:  "recordedAt",
+		TimestampFormat: time.RFC3339,
+	}
+
+	metrics, err := parser.Parse(doc)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, "room1", metrics[0].Tags()["location"])
+	assert.Equal(t, 21.5, metrics[0].Fields()["value"])
+
+	expected, err := time.Parse(time.RFC3339, "2024-01-02T15:04:05Z")
+	require.NoError(t, err)
+	assert.True(t, expected.Equal(metrics[0].Time()))
+}
+
+func TestParse_ResolvesXInclude(t *testing.T) {
+	// The document binds the XInclude namespace to "inc", not the "xi"
+	// convention, so this also exercises documentNamespacePrefixes' URI
+	// based lookup rather than the hardcoded fallback.
+	doc := []byte(`<?xml version="1.0"?>
+<root xmlns:inc="http://www.w3.org/2001/XInclude">
+  <inc:include href="other.xml"/>
+</root>`)
+
+	resolver := func(href string) ([]byte, error) {
+		if href != "other.xml" {
+			return nil, fmt.Errorf("unexpected href %q", href)
+		}
+		return []byte(`<included><value>42</value></included>`), nil
+	}
+
+	parser := &XMLParser{
+		MetricName: "root",
+		Query:      "//included",
+		Fields: []FieldConfig{
+			{Name: "value", Query: "value", Type: "int"},
+		},
+		Options: &XMLParserOptions{
+			DisallowDOCTYPE: true,
+			ResolveXInclude: true,
+			Resolver:        resolver,
+		},
+	}
+
+	metrics, err := parser.Parse(doc)
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.EqualValues(t, 42, metrics[0].Fields()["value"])
+}
+
+func TestParseStream_EnforcesMaxDepth(t *testing.T) {
+	doc := `<records><record><nested><deeper>1</deeper></nested></record></records>`
+
+	parser := &XMLParser{
+		MetricName:        "record",
+		StreamRootElement: "record",
+		Options:           &XMLParserOptions{DisallowDOCTYPE: true, MaxDepth: 2},
+	}
+
+	_, err := parser.ParseStream(strings.NewReader(doc))
+	assert.ErrorContains(t, err, "exceeds max depth")
+}
+
+func TestParseStream_EnforcesMaxElementCount(t *testing.T) {
+	doc := `<records><record><a/><b/><c/></record></records>`
+
+	parser := &XMLParser{
+		MetricName:        "record",
+		StreamRootElement: "record",
+		Options:           &XMLParserOptions{DisallowDOCTYPE: true, MaxElementCount: 2},
+	}
+
+	_, err := parser.ParseStream(strings.NewReader(doc))
+	assert.ErrorContains(t, err, "exceeds max element count")
+}
+
+func TestParseStream_EnforcesMaxTextLength(t *testing.T) {
+	doc := `<records><record><value>abcdefghij</value></record></records>`
+
+	parser := &XMLParser{
+		MetricName:        "record",
+		StreamRootElement: "record",
+		Options:           &XMLParserOptions{DisallowDOCTYPE: true, MaxTextLength: 5},
+	}
+
+	_, err := parser.ParseStream(strings.NewReader(doc))
+	assert.ErrorContains(t, err, "exceeds max length")
+}
+
+func TestParse_RejectsDOCTYPEByDefault(t *testing.T) {
+	doc := []byte(`<?xml version="1.0"?>
+<!DOCTYPE foo [ <!ENTITY xxe SYSTEM "file:///etc/passwd"> ]>
+<foo>&xxe;</foo>`)
+
+	parser := NewXMLParser("foo", false, false, false, "", "", nil, nil)
+
+	_, err := parser.Parse(doc)
+	assert.ErrorIs(t, err, errDOCTYPENotAllowed)
+}